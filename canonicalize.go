@@ -0,0 +1,71 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// trackingParams are query parameters that identify a campaign or referrer
+// rather than the content itself, so they are stripped before computing a
+// canonical URL.
+var trackingParams = map[string]bool{
+	"utm_source":   true,
+	"utm_medium":   true,
+	"utm_campaign": true,
+	"utm_term":     true,
+	"utm_content":  true,
+	"fbclid":       true,
+	"gclid":        true,
+	"mc_cid":       true,
+	"mc_eid":       true,
+	"ref":          true,
+}
+
+// canonicalURL normalizes a URL so that equivalent links (same content,
+// different tracking params or host casing) hash to the same key: it
+// lowercases the host, strips tracking query params, sorts the rest, and
+// drops any fragment.
+func canonicalURL(rawURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", err
+	}
+	u.Host = strings.ToLower(u.Host)
+	u.Fragment = ""
+
+	q := u.Query()
+	for key := range q {
+		if trackingParams[strings.ToLower(key)] {
+			q.Del(key)
+		}
+	}
+	if len(q) > 0 {
+		keys := make([]string, 0, len(q))
+		for k := range q {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		sorted := make(url.Values, len(q))
+		for _, k := range keys {
+			sorted[k] = q[k]
+		}
+		u.RawQuery = sorted.Encode()
+	} else {
+		u.RawQuery = ""
+	}
+
+	if u.Path != "/" {
+		u.Path = strings.TrimSuffix(u.Path, "/")
+	}
+	return u.String(), nil
+}
+
+// articleSHA returns the first 16 hex characters of the SHA-256 hash of a
+// canonical URL, used as the content-addressed storage key.
+func articleSHA(canonical string) string {
+	sum := sha256.Sum256([]byte(canonical))
+	return hex.EncodeToString(sum[:])[:16]
+}
@@ -0,0 +1,84 @@
+package main
+
+import "testing"
+
+func TestCanonicalURL(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "lowercases host",
+			in:   "https://Example.COM/a",
+			want: "https://example.com/a",
+		},
+		{
+			name: "strips tracking params",
+			in:   "https://example.com/a?utm_source=foo&utm_campaign=bar&id=1",
+			want: "https://example.com/a?id=1",
+		},
+		{
+			name: "sorts remaining query params",
+			in:   "https://example.com/a?b=2&a=1",
+			want: "https://example.com/a?a=1&b=2",
+		},
+		{
+			name: "drops fragment",
+			in:   "https://example.com/a#section",
+			want: "https://example.com/a",
+		},
+		{
+			name: "trims trailing slash except root",
+			in:   "https://example.com/a/",
+			want: "https://example.com/a",
+		},
+		{
+			name: "keeps root slash",
+			in:   "https://example.com/",
+			want: "https://example.com/",
+		},
+		{
+			name: "different tracking params, same content, same canonical URL",
+			in:   "https://example.com/a?utm_source=newsletter",
+			want: "https://example.com/a",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := canonicalURL(tc.in)
+			if err != nil {
+				t.Fatalf("canonicalURL(%q) returned error: %v", tc.in, err)
+			}
+			if got != tc.want {
+				t.Errorf("canonicalURL(%q) = %q, want %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestArticleSHAIsStableAndDistinct(t *testing.T) {
+	a, err := canonicalURL("https://example.com/a?utm_source=foo")
+	if err != nil {
+		t.Fatalf("canonicalURL returned error: %v", err)
+	}
+	b, err := canonicalURL("https://example.com/a?utm_source=bar")
+	if err != nil {
+		t.Fatalf("canonicalURL returned error: %v", err)
+	}
+	if articleSHA(a) != articleSHA(b) {
+		t.Errorf("expected equivalent canonical URLs to hash to the same key")
+	}
+
+	c, err := canonicalURL("https://example.com/b")
+	if err != nil {
+		t.Fatalf("canonicalURL returned error: %v", err)
+	}
+	if articleSHA(a) == articleSHA(c) {
+		t.Errorf("expected distinct canonical URLs to hash to different keys")
+	}
+
+	if len(articleSHA(a)) != 16 {
+		t.Errorf("expected a 16-character SHA, got %d characters", len(articleSHA(a)))
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// FeedParser turns a partner feed's raw body into a list of FeedItems.
+// Implementations are looked up in feedParsers by format name, as picked
+// by detectFormat or given explicitly on a PartnerFeed.
+type FeedParser interface {
+	Parse(body []byte) ([]FeedItem, error)
+}
+
+var feedParsers = map[string]FeedParser{
+	"yaml":     yamlFeedParser{},
+	"rss":      rssFeedParser{},
+	"atom":     atomFeedParser{},
+	"jsonfeed": jsonFeedParser{},
+}
+
+// detectFormat picks a feed format from the HTTP Content-Type, falling
+// back to sniffing the body when the header is missing or generic (e.g.
+// "text/xml" for both RSS and Atom).
+func detectFormat(contentType string, body []byte) string {
+	contentType = strings.ToLower(contentType)
+	switch {
+	case strings.Contains(contentType, "json"):
+		return "jsonfeed"
+	case strings.Contains(contentType, "atom"):
+		return "atom"
+	case strings.Contains(contentType, "rss"):
+		return "rss"
+	}
+
+	trimmed := bytes.TrimSpace(body)
+	sniffLen := len(trimmed)
+	if sniffLen > 512 {
+		sniffLen = 512
+	}
+	sniff := trimmed[:sniffLen]
+
+	switch {
+	case len(trimmed) > 0 && trimmed[0] == '{':
+		return "jsonfeed"
+	case bytes.Contains(sniff, []byte("<rss")):
+		return "rss"
+	case bytes.Contains(sniff, []byte("<feed")):
+		return "atom"
+	}
+
+	if strings.Contains(contentType, "xml") {
+		return "rss"
+	}
+	return "yaml"
+}
+
+// parseFeedTime tries the timestamp layouts used by RSS, Atom, and JSON
+// Feed, in that order.
+func parseFeedTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, fmt.Errorf("empty timestamp")
+	}
+	for _, layout := range []string{time.RFC1123Z, time.RFC1123, time.RFC3339} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized feed timestamp %q", value)
+}
+
+// yamlFeedParser parses the custom YAML shape used by persagg.com, the
+// original and still most common partner feed format.
+type yamlFeedParser struct{}
+
+func (yamlFeedParser) Parse(body []byte) ([]FeedItem, error) {
+	var items []FeedItem
+	if err := yaml.Unmarshal(body, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// rssFeedParser parses RSS 2.0 feeds.
+type rssFeedParser struct{}
+
+type rssFeed struct {
+	XMLName xml.Name `xml:"rss"`
+	Channel struct {
+		Items []struct {
+			Link    string `xml:"link"`
+			Title   string `xml:"title"`
+			PubDate string `xml:"pubDate"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+func (rssFeedParser) Parse(body []byte) ([]FeedItem, error) {
+	var feed rssFeed
+	if err := xml.Unmarshal(body, &feed); err != nil {
+		return nil, err
+	}
+	items := make([]FeedItem, 0, len(feed.Channel.Items))
+	for _, it := range feed.Channel.Items {
+		if it.Link == "" {
+			continue
+		}
+		item := FeedItem{URL: it.Link, Name: it.Title}
+		if t, err := parseFeedTime(it.PubDate); err == nil {
+			item.PublishedAt = &t
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// atomFeedParser parses Atom 1.0 feeds.
+type atomFeedParser struct{}
+
+type atomFeed struct {
+	XMLName xml.Name `xml:"feed"`
+	Lang    string   `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+	Entries []struct {
+		Title     string `xml:"title"`
+		Published string `xml:"published"`
+		Links     []struct {
+			Href string `xml:"href,attr"`
+			Rel  string `xml:"rel,attr"`
+		} `xml:"link"`
+	} `xml:"entry"`
+}
+
+func (atomFeedParser) Parse(body []byte) ([]FeedItem, error) {
+	var feed atomFeed
+	if err := xml.Unmarshal(body, &feed); err != nil {
+		return nil, err
+	}
+	items := make([]FeedItem, 0, len(feed.Entries))
+	for _, e := range feed.Entries {
+		var link string
+		for _, l := range e.Links {
+			if l.Rel == "" || l.Rel == "alternate" {
+				link = l.Href
+				break
+			}
+		}
+		if link == "" {
+			continue
+		}
+		item := FeedItem{URL: link, Name: e.Title, Lang: feed.Lang}
+		if t, err := parseFeedTime(e.Published); err == nil {
+			item.PublishedAt = &t
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// jsonFeedParser parses JSON Feed 1.1 feeds.
+type jsonFeedParser struct{}
+
+type jsonFeedDoc struct {
+	Language string `json:"language"`
+	Items    []struct {
+		URL           string `json:"url"`
+		Title         string `json:"title"`
+		DatePublished string `json:"date_published"`
+		Language      string `json:"language"`
+	} `json:"items"`
+}
+
+func (jsonFeedParser) Parse(body []byte) ([]FeedItem, error) {
+	var feed jsonFeedDoc
+	if err := json.Unmarshal(body, &feed); err != nil {
+		return nil, err
+	}
+	items := make([]FeedItem, 0, len(feed.Items))
+	for _, it := range feed.Items {
+		if it.URL == "" {
+			continue
+		}
+		lang := it.Language
+		if lang == "" {
+			lang = feed.Language
+		}
+		item := FeedItem{URL: it.URL, Name: it.Title, Lang: lang}
+		if t, err := parseFeedTime(it.DatePublished); err == nil {
+			item.PublishedAt = &t
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import "testing"
+
+func TestDetectFormat(t *testing.T) {
+	cases := []struct {
+		name        string
+		contentType string
+		body        string
+		want        string
+	}{
+		{"json body sniffed as jsonfeed", "", `{"items":[]}`, "jsonfeed"},
+		{"rss body sniffed despite generic content-type", "text/xml", `<rss version="2.0"><channel></channel></rss>`, "rss"},
+		{"atom body sniffed despite generic content-type", "text/xml", `<feed xmlns="http://www.w3.org/2005/Atom"></feed>`, "atom"},
+		{"json content-type with ambiguous body", "application/json; charset=utf-8", `not sniffable`, "jsonfeed"},
+		{"atom content-type", "application/atom+xml", `not sniffable`, "atom"},
+		{"rss content-type", "application/rss+xml", `not sniffable`, "rss"},
+		{"generic xml content-type falls back to rss", "text/xml", `not sniffable`, "rss"},
+		{"unrecognized content-type falls back to yaml", "text/plain", `- url: https://example.com`, "yaml"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := detectFormat(tc.contentType, []byte(tc.body))
+			if got != tc.want {
+				t.Errorf("detectFormat(%q, %q) = %q, want %q", tc.contentType, tc.body, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseFeedTime(t *testing.T) {
+	cases := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{"rfc1123z", "Mon, 02 Jan 2006 15:04:05 -0700", false},
+		{"rfc1123", "Mon, 02 Jan 2006 15:04:05 MST", false},
+		{"rfc3339", "2006-01-02T15:04:05Z", false},
+		{"empty", "", true},
+		{"garbage", "not a timestamp", true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseFeedTime(tc.value)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("parseFeedTime(%q) error = %v, wantErr %v", tc.value, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestRSSFeedParser(t *testing.T) {
+	body := `<rss version="2.0"><channel>
+		<item><link>https://example.com/a</link><title>A</title><pubDate>Mon, 02 Jan 2006 15:04:05 -0700</pubDate></item>
+		<item><link></link><title>No link</title></item>
+	</channel></rss>`
+
+	items, err := rssFeedParser{}.Parse([]byte(body))
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("got %d items, want 1 (empty-link item should be dropped)", len(items))
+	}
+	if items[0].URL != "https://example.com/a" || items[0].Name != "A" {
+		t.Errorf("unexpected item: %+v", items[0])
+	}
+	if items[0].PublishedAt == nil {
+		t.Errorf("expected PublishedAt to be set")
+	}
+}
+
+func TestAtomFeedParser(t *testing.T) {
+	body := `<feed xmlns="http://www.w3.org/2005/Atom" xml:lang="zh">
+		<entry>
+			<title>A</title>
+			<published>2006-01-02T15:04:05Z</published>
+			<link rel="self" href="https://example.com/self"/>
+			<link rel="alternate" href="https://example.com/a"/>
+		</entry>
+		<entry>
+			<title>No alternate link</title>
+			<link rel="self" href="https://example.com/self2"/>
+		</entry>
+	</feed>`
+
+	items, err := atomFeedParser{}.Parse([]byte(body))
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("got %d items, want 1 (entry with no alternate link should be dropped)", len(items))
+	}
+	if items[0].URL != "https://example.com/a" || items[0].Lang != "zh" {
+		t.Errorf("unexpected item: %+v", items[0])
+	}
+}
+
+func TestJSONFeedParser(t *testing.T) {
+	body := `{
+		"language": "fa",
+		"items": [
+			{"url": "https://example.com/a", "title": "A", "date_published": "2006-01-02T15:04:05Z"},
+			{"url": "https://example.com/b", "title": "B", "language": "zh"},
+			{"url": "", "title": "No url"}
+		]
+	}`
+
+	items, err := jsonFeedParser{}.Parse([]byte(body))
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("got %d items, want 2 (empty-url item should be dropped)", len(items))
+	}
+	if items[0].Lang != "fa" {
+		t.Errorf("item 0 should fall back to feed-level language, got %q", items[0].Lang)
+	}
+	if items[1].Lang != "zh" {
+		t.Errorf("item 1 should keep its own language, got %q", items[1].Lang)
+	}
+	if items[0].PublishedAt == nil || items[1].PublishedAt != nil {
+		t.Errorf("PublishedAt should only be set when date_published parses: %+v %+v", items[0], items[1])
+	}
+}
+
+func TestYAMLFeedParser(t *testing.T) {
+	body := "- url: https://example.com/a\n  name: A\n"
+	items, err := yamlFeedParser{}.Parse([]byte(body))
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if len(items) != 1 || items[0].URL != "https://example.com/a" {
+		t.Errorf("unexpected items: %+v", items)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-shiori/go-readability"
+)
+
+// redirectTimeout bounds each resolveRedirect HEAD request, so a single
+// non-responding or slow-TLS-handshake partner host can't hang the crawl.
+const redirectTimeout = 10 * time.Second
+
+// articleFetcher fetches and extracts readable article content over a
+// shared, context-aware http.Client, rather than letting readability.FromURL
+// open its own connection per call.
+type articleFetcher struct {
+	client  *http.Client
+	limiter *hostLimiter
+}
+
+func newArticleFetcher(client *http.Client, limiter *hostLimiter) *articleFetcher {
+	return &articleFetcher{client: client, limiter: limiter}
+}
+
+func (f *articleFetcher) Fetch(ctx context.Context, rawURL string) (string, error) {
+	if err := f.limiter.forURL(rawURL).Wait(ctx); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return "", &httpStatusError{URL: rawURL, StatusCode: resp.StatusCode}
+	}
+
+	pageURL, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	article, err := readability.FromReader(resp.Body, pageURL)
+	if err != nil {
+		return "", err
+	}
+	return article.TextContent, nil
+}
+
+// httpStatusError carries the HTTP status code of a failed fetch, so
+// isRetryable can tell a transient 5xx/429 apart from a permanent 4xx.
+type httpStatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("fetching %s: unexpected status %d", e.URL, e.StatusCode)
+}
+
+// resolveRedirect follows at most one redirect hop from rawURL and returns
+// the destination, so dedup can key on where a link actually points
+// without chasing an arbitrarily long redirect chain. If rawURL isn't a
+// redirect (or the request fails), it is returned unchanged. The request is
+// paced through limiter like any other fetch, and bounded by
+// redirectTimeout so one unresponsive host can't hang the whole crawl.
+func resolveRedirect(ctx context.Context, client *http.Client, limiter *hostLimiter, rawURL string) string {
+	ctx, cancel := context.WithTimeout(ctx, redirectTimeout)
+	defer cancel()
+
+	if err := limiter.forURL(rawURL).Wait(ctx); err != nil {
+		return rawURL
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, rawURL, nil)
+	if err != nil {
+		return rawURL
+	}
+
+	noFollow := &http.Client{
+		Transport: client.Transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := noFollow.Do(req)
+	if err != nil {
+		return rawURL
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 300 || resp.StatusCode >= 400 {
+		return rawURL
+	}
+	loc := resp.Header.Get("Location")
+	if loc == "" {
+		return rawURL
+	}
+	resolved, err := resp.Request.URL.Parse(loc)
+	if err != nil {
+		return rawURL
+	}
+	return resolved.String()
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
+)
+
+// legacyArticle is the pre-content-addressed index shape: a monotonic ID
+// and a single URL per article.
+type legacyArticle struct {
+	ID    int64  `yaml:"id"`
+	URL   string `yaml:"url"`
+	Title string `yaml:"title"`
+	Lang  string `yaml:"lang,omitempty"`
+}
+
+func (a legacyArticle) legacyFilename() string {
+	return fmt.Sprintf("%d.html", a.ID)
+}
+
+// decodeIndex reads an index that may be in either the current
+// content-addressed format or the legacy numeric-ID format, migrating the
+// latter in memory. isLegacy reports whether migration occurred, so the
+// caller knows to copy each article's content over to its new, hash-based
+// key via migrateLegacyContent.
+func decodeIndex(data []byte) (articles []Article, isLegacy bool, err error) {
+	if len(data) == 0 {
+		return nil, false, nil
+	}
+
+	var current []Article
+	if yaml.Unmarshal(data, &current) == nil && allHaveSHA(current) {
+		return current, false, nil
+	}
+
+	var legacy []legacyArticle
+	if err := yaml.Unmarshal(data, &legacy); err != nil {
+		return nil, false, err
+	}
+	migrated := make([]Article, 0, len(legacy))
+	for _, a := range legacy {
+		canonical, cerr := canonicalURL(a.URL)
+		if cerr != nil {
+			canonical = a.URL
+		}
+		migrated = append(migrated, Article{
+			SHA:            articleSHA(canonical),
+			URLs:           []string{a.URL},
+			Title:          a.Title,
+			Lang:           a.Lang,
+			LegacyFilename: a.legacyFilename(),
+		})
+	}
+	return migrated, true, nil
+}
+
+func allHaveSHA(articles []Article) bool {
+	for _, a := range articles {
+		if a.SHA == "" {
+			return false
+		}
+	}
+	return true
+}
+
+func encodeIndex(articles []Article) ([]byte, error) {
+	return yaml.Marshal(articles)
+}
+
+// migrateLegacyContent copies each migrated article's content from its old
+// numeric-ID key to its new content-addressed key. It is a one-time shim:
+// once the index is re-saved in the current format, LegacyFilename is
+// empty and this is a no-op.
+func migrateLegacyContent(ctx context.Context, store Store, log *zap.Logger, articles []Article) {
+	for _, a := range articles {
+		if a.LegacyFilename == "" {
+			continue
+		}
+		content, err := store.GetArticle(ctx, a.LegacyFilename)
+		if err != nil || content == nil {
+			log.Warn("could not migrate legacy article content",
+				zap.String("legacy_filename", a.LegacyFilename), zap.String("sha", a.SHA), zap.Error(err))
+			continue
+		}
+		if err := store.PutArticle(ctx, a.Filename(), content); err != nil {
+			log.Warn("could not write migrated article content",
+				zap.String("legacy_filename", a.LegacyFilename), zap.String("sha", a.SHA), zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import "testing"
+
+func TestDecodeIndexEmpty(t *testing.T) {
+	articles, isLegacy, err := decodeIndex(nil)
+	if err != nil {
+		t.Fatalf("decodeIndex returned error: %v", err)
+	}
+	if articles != nil || isLegacy {
+		t.Errorf("decodeIndex(nil) = %+v, %v, want nil, false", articles, isLegacy)
+	}
+}
+
+func TestDecodeIndexCurrentFormat(t *testing.T) {
+	data := []byte(`
+- sha: abc123
+  urls:
+    - https://example.com/a
+  title: A
+  first_seen: 2006-01-02T15:04:05Z
+  last_seen: 2006-01-02T15:04:05Z
+`)
+	articles, isLegacy, err := decodeIndex(data)
+	if err != nil {
+		t.Fatalf("decodeIndex returned error: %v", err)
+	}
+	if isLegacy {
+		t.Errorf("expected isLegacy = false for a current-format index")
+	}
+	if len(articles) != 1 || articles[0].SHA != "abc123" {
+		t.Errorf("unexpected articles: %+v", articles)
+	}
+}
+
+func TestDecodeIndexLegacyFormat(t *testing.T) {
+	data := []byte(`
+- id: 42
+  url: https://example.com/a
+  title: A
+  lang: zh
+`)
+	articles, isLegacy, err := decodeIndex(data)
+	if err != nil {
+		t.Fatalf("decodeIndex returned error: %v", err)
+	}
+	if !isLegacy {
+		t.Fatalf("expected isLegacy = true for a numeric-ID index")
+	}
+	if len(articles) != 1 {
+		t.Fatalf("got %d articles, want 1", len(articles))
+	}
+	a := articles[0]
+	if a.SHA == "" {
+		t.Errorf("expected a SHA to be assigned during migration")
+	}
+	if a.LegacyFilename != "42.html" {
+		t.Errorf("LegacyFilename = %q, want 42.html", a.LegacyFilename)
+	}
+	if len(a.URLs) != 1 || a.URLs[0] != "https://example.com/a" {
+		t.Errorf("unexpected URLs: %+v", a.URLs)
+	}
+	if a.Lang != "zh" {
+		t.Errorf("Lang = %q, want zh", a.Lang)
+	}
+}
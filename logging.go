@@ -0,0 +1,10 @@
+package main
+
+import "go.uber.org/zap"
+
+// newLogger builds the crawler's structured logger. Every log line is
+// JSON so operators can grep/alert on fields like stage, article_id, or
+// error across runs.
+func newLogger() (*zap.Logger, error) {
+	return zap.NewProduction()
+}
@@ -1,169 +1,212 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"errors"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"os"
+	"os/signal"
 	"sort"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/config"
-
-	"github.com/aws/aws-sdk-go-v2/service/s3/types"
-
-	"gopkg.in/yaml.v2"
-
-	"github.com/aws/aws-sdk-go-v2/aws"
-	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
-	"github.com/go-shiori/go-readability"
+	"go.uber.org/zap"
 )
 
+// Article is a manifest entry for one piece of deduplicated content: all
+// the raw feed URLs that resolve to it, keyed by the SHA-256 of its
+// canonical URL.
 type Article struct {
-	ID    int64  `yaml:"id"`
-	URL   string `yaml:"url"`
-	Title string `yaml:"title"`
+	SHA       string    `yaml:"sha"`
+	URLs      []string  `yaml:"urls"`
+	Title     string    `yaml:"title"`
+	Lang      string    `yaml:"lang,omitempty"`
+	FirstSeen time.Time `yaml:"first_seen"`
+	LastSeen  time.Time `yaml:"last_seen"`
+
+	// LegacyFilename is set only while migrating a legacy numeric-ID
+	// index, so migrateLegacyContent knows where to copy content from. It
+	// is never itself persisted.
+	LegacyFilename string `yaml:"-"`
 }
 
 func (a Article) Filename() string {
-	return fmt.Sprintf("%d.html", a.ID)
+	return a.SHA + "/content.html"
 }
 
+func containsString(haystack []string, needle string) bool {
+	for _, s := range haystack {
+		if s == needle {
+			return true
+		}
+	}
+	return false
+}
+
+// FeedItem is the normalized shape every FeedParser produces, regardless
+// of the partner feed's native format.
 type FeedItem struct {
-	URL  string
-	Name string
+	URL         string     `yaml:"url"`
+	Name        string     `yaml:"name"`
+	PublishedAt *time.Time `yaml:"published_at,omitempty"`
+	Lang        string     `yaml:"lang,omitempty"`
 }
 
-const LanternAdsBucket = "lantern-ads"
 const LanternAdsIndex = "index.yaml"
 
-var PartnerFeeds = []string{
-	"https://www.persagg.com/zh.yaml",
-	//"https://www.persagg.com/zh-week.yaml",
-	//"https://www.persagg.com/fa.yaml",
-	//"https://www.persagg.com/fa-week.yaml",
+// DefaultStoreURL is used when neither --store nor STORE_URL is set, to
+// preserve the original S3-only behavior.
+const DefaultStoreURL = "s3://lantern-ads"
+
+// DefaultWorkers is the number of articles downloaded concurrently when
+// --workers is not set.
+const DefaultWorkers = 8
+
+// PartnerFeed describes one feed to crawl. Format is an explicit hint
+// ("yaml", "rss", "atom", "jsonfeed"); leave it empty to auto-detect from
+// the response's Content-Type and body. MaxItems caps how many items are
+// taken from the feed (0 means unlimited), and Locale is stamped onto any
+// item that doesn't carry its own Lang.
+type PartnerFeed struct {
+	URL      string
+	Format   string
+	MaxItems int
+	Locale   string
 }
 
-func loadFeedItems() []FeedItem {
+var PartnerFeeds = []PartnerFeed{
+	{URL: "https://www.persagg.com/zh.yaml", Locale: "zh"},
+	//{URL: "https://www.persagg.com/zh-week.yaml", Locale: "zh"},
+	//{URL: "https://www.persagg.com/fa.yaml", Locale: "fa"},
+	//{URL: "https://www.persagg.com/fa-week.yaml", Locale: "fa"},
+}
+
+func loadFeedItems(log *zap.Logger) []FeedItem {
 	var items []FeedItem
-	for _, url := range PartnerFeeds {
-		resp, err := http.Get(url)
+	for _, feed := range PartnerFeeds {
+		resp, err := http.Get(feed.URL)
 		if err != nil {
-			fmt.Println(err)
+			log.Error("fetching partner feed", zap.String("stage", "load_feed"), zap.String("url", feed.URL), zap.Error(err))
 			continue
 		}
 
 		b, err := ioutil.ReadAll(resp.Body)
+		contentType := resp.Header.Get("Content-Type")
 		_ = resp.Body.Close()
 		if err != nil {
-			fmt.Println(err)
+			log.Error("reading partner feed body", zap.String("stage", "load_feed"), zap.String("url", feed.URL), zap.Error(err))
 			continue
 		}
-		var feed []FeedItem
-		err = yaml.Unmarshal(b, &feed)
+
+		format := feed.Format
+		if format == "" {
+			format = detectFormat(contentType, b)
+		}
+		parser, ok := feedParsers[format]
+		if !ok {
+			log.Error("unknown feed format", zap.String("stage", "load_feed"), zap.String("url", feed.URL), zap.String("format", format))
+			continue
+		}
+
+		parsed, err := parser.Parse(b)
 		if err != nil {
-			fmt.Println(err)
+			log.Error("parsing partner feed", zap.String("stage", "load_feed"), zap.String("url", feed.URL), zap.String("format", format), zap.Error(err))
 			continue
 		}
-		items = append(items, feed...)
+		if feed.MaxItems > 0 && len(parsed) > feed.MaxItems {
+			parsed = parsed[:feed.MaxItems]
+		}
+		for i := range parsed {
+			if parsed[i].Lang == "" {
+				parsed[i].Lang = feed.Locale
+			}
+		}
+		items = append(items, parsed...)
 	}
 	return items
 }
 
-func getCurrentIndex(client *s3.Client) (nextID int64, currentArticles []Article) {
-	indexData, err := client.GetObject(context.TODO(), &s3.GetObjectInput{
-		Bucket: aws.String(LanternAdsBucket),
-		Key:    aws.String(LanternAdsIndex),
-	})
+// resolvedFeedItem is a FeedItem with its dedup key already computed, so
+// diffArticles never has to do network or hashing work itself.
+type resolvedFeedItem struct {
+	FeedItem
+	SHA string
+}
 
-	if err != nil {
-		var responseError *awshttp.ResponseError
-		if !errors.As(err, &responseError) || responseError.ResponseError.HTTPStatusCode() != http.StatusNotFound {
-			panic(fmt.Errorf("problem accessing s3 bucket. check if env variables for AWS are set: %v", err))
-		}
-		// all good, no index yet
-	} else {
-		err := yaml.NewDecoder(indexData.Body).Decode(&currentArticles)
-		defer func() { _ = indexData.Body.Close() }()
+// resolveFeedItems follows one redirect hop per item and computes its
+// content-addressed key, so items that point at the same canonical URL
+// (after stripping tracking params) dedup to a single article. Each
+// redirect lookup is paced through limiter, the same per-host rate
+// limiter used for article downloads.
+func resolveFeedItems(ctx context.Context, client *http.Client, limiter *hostLimiter, items []FeedItem, log *zap.Logger) []resolvedFeedItem {
+	resolved := make([]resolvedFeedItem, 0, len(items))
+	for _, item := range items {
+		dest := resolveRedirect(ctx, client, limiter, item.URL)
+		canonical, err := canonicalURL(dest)
 		if err != nil {
-			panic(err)
+			log.Warn("could not canonicalize feed item URL", zap.String("url", item.URL), zap.Error(err))
+			canonical = dest
 		}
+		resolved = append(resolved, resolvedFeedItem{FeedItem: item, SHA: articleSHA(canonical)})
 	}
-	nextID = 1
-	if len(currentArticles) > 0 {
-		nextID = currentArticles[len(currentArticles)-1].ID + 1
-	}
-	return
+	return resolved
 }
 
-func diffArticles(currentArticles []Article, feedItems []FeedItem, nextID int64) (articlesToDownload []Article, newIndex []Article) {
-	wantUrl := func(url string) bool {
-		for _, item := range feedItems {
-			if item.URL == url {
-				return true
-			}
-		}
-		return false
-	}
-	newUrl := func(url string) bool {
-		for _, item := range currentArticles {
-			if item.URL == url {
-				return false
-			}
-		}
-		return true
-	}
+func diffArticles(currentArticles []Article, feedItems []resolvedFeedItem, now time.Time) (articlesToDownload []Article, newIndex []Article) {
+	bySHA := make(map[string]Article, len(currentArticles))
 	for _, a := range currentArticles {
-		// if current article url is still in the feed, keep it
-		if wantUrl(a.URL) {
-			newIndex = append(newIndex, a)
-		}
+		bySHA[a.SHA] = a
 	}
+	pos := make(map[string]int, len(feedItems))
+
 	for _, item := range feedItems {
-		// if the feed item url is not in the current articles, queue it up for download
-		if newUrl(item.URL) {
-			a := Article{
-				ID:    nextID,
-				URL:   item.URL,
-				Title: item.Name,
+		if idx, ok := pos[item.SHA]; ok {
+			// another feed URL resolving to an article already queued this run
+			if !containsString(newIndex[idx].URLs, item.URL) {
+				newIndex[idx].URLs = append(newIndex[idx].URLs, item.URL)
 			}
-			articlesToDownload = append(articlesToDownload, a)
-			newIndex = append(newIndex, a)
-			nextID++
+			continue
 		}
-	}
-	return
-}
 
-func updateIndex(index []Article, client *s3.Client) {
-	sort.SliceStable(index, func(i, j int) bool {
-		return index[i].ID < index[j].ID
-	})
-	data, _ := yaml.Marshal(index)
-
-	_, err := client.PutObject(context.TODO(), &s3.PutObjectInput{
-		Bucket: aws.String(LanternAdsBucket),
-		Key:    aws.String(LanternAdsIndex),
-		Body:   bytes.NewReader(data),
-	})
-	if err != nil {
-		panic(err)
+		if prior, ok := bySHA[item.SHA]; ok {
+			prior.LastSeen = now
+			if !containsString(prior.URLs, item.URL) {
+				prior.URLs = append(prior.URLs, item.URL)
+			}
+			if item.Lang != "" {
+				prior.Lang = item.Lang
+			}
+			newIndex = append(newIndex, prior)
+			pos[item.SHA] = len(newIndex) - 1
+			continue
+		}
+
+		a := Article{
+			SHA:       item.SHA,
+			URLs:      []string{item.URL},
+			Title:     item.Name,
+			Lang:      item.Lang,
+			FirstSeen: now,
+			LastSeen:  now,
+		}
+		articlesToDownload = append(articlesToDownload, a)
+		newIndex = append(newIndex, a)
+		pos[item.SHA] = len(newIndex) - 1
 	}
+	return
 }
 
-func deleteArticles(newIndex []Article, client *s3.Client) int {
-	resp, err := client.ListObjects(context.TODO(), &s3.ListObjectsInput{
-		Bucket: aws.String(LanternAdsBucket),
-	})
-
+// staleArticleKeys reports which stored article keys no longer have an
+// entry in newIndex, without deleting anything, so --dry-run can preview
+// exactly what deleteArticles would remove.
+func staleArticleKeys(ctx context.Context, newIndex []Article, store Store) ([]string, error) {
+	keys, err := store.ListArticles(ctx)
 	if err != nil {
-		fmt.Printf("Cannot list s3 bucket: %v", err)
-		return 0
+		return nil, fmt.Errorf("listing stored articles: %w", err)
 	}
 
 	wantedArticle := func(fn string) bool {
@@ -175,86 +218,226 @@ func deleteArticles(newIndex []Article, client *s3.Client) int {
 		return false
 	}
 
-	deleteInput := &s3.DeleteObjectsInput{
-		Bucket: aws.String(LanternAdsBucket),
-		Delete: &types.Delete{
-			Objects: nil,
-			Quiet:   true,
-		},
-	}
-
-	for _, item := range resp.Contents {
-		if *item.Key != "index.yaml" && !wantedArticle(*item.Key) {
-			deleteInput.Delete.Objects = append(deleteInput.Delete.Objects, types.ObjectIdentifier{
-				Key: item.Key,
-			})
+	var stale []string
+	for _, key := range keys {
+		if !wantedArticle(key) {
+			stale = append(stale, key)
 		}
 	}
+	return stale, nil
+}
 
-	res, err := client.DeleteObjects(context.TODO(), deleteInput)
-
+func deleteArticles(ctx context.Context, newIndex []Article, store Store, log *zap.Logger) int {
+	toDelete, err := staleArticleKeys(ctx, newIndex, store)
 	if err != nil {
-		fmt.Printf("Cannot delete old articles: %v", err)
+		log.Error("listing stale articles", zap.String("stage", "delete"), zap.Error(err))
+		return 0
 	}
-	return len(res.Deleted)
+
+	if err := store.DeleteArticles(ctx, toDelete); err != nil {
+		log.Error("deleting stale articles", zap.String("stage", "delete"), zap.Error(err))
+		return 0
+	}
+	return len(toDelete)
 }
 
-func downloadArticles(articlesToDownload []Article, newIndex []Article, client *s3.Client) uint64 {
-	wg := sync.WaitGroup{}
-	downloaded := uint64(0)
-	var badIDs sync.Map
+func downloadArticles(ctx context.Context, articlesToDownload []Article, newIndex []Article, store Store, fetcher *articleFetcher, workers int, progress *downloadProgress, log *zap.Logger) (downloaded uint64, failures []FailedArticle) {
+	var badSHAs sync.Map
+	var failuresMu sync.Mutex
+	defer progress.Finish()
 
-	for _, article := range articlesToDownload {
+	work := make(chan Article)
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
 		wg.Add(1)
-		go func(article Article) {
+		go func() {
 			defer wg.Done()
-			a, err := readability.FromURL(article.URL, 30*time.Second)
-			if err != nil {
-				fmt.Printf("Unable to fetch origin article at %v: %v", article.URL, err)
-				badIDs.Store(article.ID, true)
-				return
+			for article := range work {
+				start := time.Now()
+				url := article.URLs[0]
+
+				var content string
+				fetch := withRetry(ctx, defaultRetryConfig, func(ctx context.Context) error {
+					c, err := fetcher.Fetch(ctx, url)
+					if err != nil {
+						return err
+					}
+					content = c
+					return nil
+				})
+				if fetch.Err != nil {
+					log.Warn("unable to fetch origin article",
+						zap.String("sha", article.SHA), zap.String("url", url),
+						zap.String("stage", "fetch"), zap.Int("attempt", fetch.Attempts),
+						zap.Int64("duration_ms", time.Since(start).Milliseconds()), zap.Error(fetch.Err))
+					badSHAs.Store(article.SHA, true)
+					failuresMu.Lock()
+					failures = append(failures, FailedArticle{SHA: article.SHA, URL: url, Attempts: fetch.Attempts, Error: fetch.Err.Error()})
+					failuresMu.Unlock()
+					progress.Failure()
+					continue
+				}
+
+				upload := withRetry(ctx, defaultRetryConfig, func(ctx context.Context) error {
+					return store.PutArticle(ctx, article.Filename(), []byte(content))
+				})
+				if upload.Err != nil {
+					log.Warn("unable to upload article",
+						zap.String("sha", article.SHA), zap.String("url", url),
+						zap.String("stage", "upload"), zap.Int("attempt", upload.Attempts),
+						zap.Int64("duration_ms", time.Since(start).Milliseconds()), zap.Error(upload.Err))
+					badSHAs.Store(article.SHA, true)
+					failuresMu.Lock()
+					failures = append(failures, FailedArticle{SHA: article.SHA, URL: url, Attempts: upload.Attempts, Error: upload.Err.Error()})
+					failuresMu.Unlock()
+					progress.Failure()
+					continue
+				}
+				atomic.AddUint64(&downloaded, 1)
+				progress.Success()
 			}
-			_, err = client.PutObject(context.TODO(), &s3.PutObjectInput{
-				Bucket: aws.String(LanternAdsBucket),
-				Key:    aws.String(article.Filename()),
-				Body:   bytes.NewReader([]byte(a.TextContent)),
-			})
-			if err != nil {
-				fmt.Printf("Unable to upload article %v: %v", article.ID, err)
-				badIDs.Store(article.ID, true)
-				return
+		}()
+	}
+
+feedLoop:
+	for i, article := range articlesToDownload {
+		select {
+		case work <- article:
+		case <-ctx.Done():
+			// mark the remaining, unsent articles as failed so a cancelled
+			// run doesn't leave half-downloaded articles in the index
+			for _, remaining := range articlesToDownload[i:] {
+				badSHAs.Store(remaining.SHA, true)
+				failures = append(failures, FailedArticle{SHA: remaining.SHA, URL: remaining.URLs[0], Error: ctx.Err().Error()})
+				progress.Failure()
 			}
-			atomic.AddUint64(&downloaded, 1)
-		}(article)
+			break feedLoop
+		}
 	}
+	close(work)
 	wg.Wait()
+
 	// clean up the index from articles that failed to download
 	i := 0 // output index
 	for _, x := range newIndex {
-		if _, ok := badIDs.Load(x.ID); ok {
+		if _, ok := badSHAs.Load(x.SHA); ok {
 			continue
 		}
 		// copy and increment index
 		newIndex[i] = x
 		i++
 	}
-	return downloaded
+	return downloaded, failures
 }
 
 func main() {
-	cfg, err := config.LoadDefaultConfig(context.TODO())
+	storeURL := flag.String("store", "", "store URL, e.g. s3://bucket, gs://bucket, or file:///path (defaults to $STORE_URL or "+DefaultStoreURL+")")
+	workers := flag.Int("workers", DefaultWorkers, "number of articles to download concurrently")
+	hostRate := flag.Float64("rate", 2, "max requests per second to any single partner host")
+	dryRun := flag.Bool("dry-run", false, "compute and log what would be added/deleted without touching the store")
+	showProgress := flag.Bool("progress", false, "render a live progress bar over article downloads")
+	flag.Parse()
+
+	log, err := newLogger()
 	if err != nil {
 		panic(err)
 	}
-	client := s3.NewFromConfig(cfg)
-
-	items := loadFeedItems()
-	nextID, currentArticles := getCurrentIndex(client)
-	articlesToDownload, newIndex := diffArticles(currentArticles, items, nextID)
-	deleted := deleteArticles(newIndex, client)
-	downloaded := downloadArticles(articlesToDownload, newIndex, client)
-	if deleted > 0 || downloaded > 0 {
-		updateIndex(newIndex, client)
+	defer func() { _ = log.Sync() }()
+
+	start := time.Now()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	resolvedStoreURL := *storeURL
+	if resolvedStoreURL == "" {
+		resolvedStoreURL = os.Getenv("STORE_URL")
+	}
+	if resolvedStoreURL == "" {
+		resolvedStoreURL = DefaultStoreURL
+	}
+
+	store, err := NewStore(resolvedStoreURL)
+	if err != nil {
+		log.Fatal("constructing store", zap.Error(err))
 	}
 
+	client := &http.Client{
+		Transport: &http.Transport{
+			MaxIdleConns:        100,
+			MaxIdleConnsPerHost: 10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+	limiter := newHostLimiter(*hostRate, 1)
+	fetcher := newArticleFetcher(client, limiter)
+
+	items := loadFeedItems(log)
+
+	indexData, err := store.GetIndex(ctx)
+	if err != nil {
+		log.Fatal("reading current index", zap.Error(err))
+	}
+	currentArticles, isLegacy, err := decodeIndex(indexData)
+	if err != nil {
+		log.Fatal("decoding current index", zap.Error(err))
+	}
+	if isLegacy && !*dryRun {
+		log.Info("migrating legacy numeric-ID index to content-addressed storage", zap.Int("articles", len(currentArticles)))
+		migrateLegacyContent(ctx, store, log, currentArticles)
+	}
+
+	resolved := resolveFeedItems(ctx, client, limiter, items, log)
+	articlesToDownload, newIndex := diffArticles(currentArticles, resolved, time.Now())
+	kept := len(newIndex) - len(articlesToDownload)
+
+	if *dryRun {
+		toDelete, err := staleArticleKeys(ctx, newIndex, store)
+		if err != nil {
+			log.Fatal("listing stale articles", zap.Error(err))
+		}
+		log.Info("dry run: no changes were made",
+			zap.Int("feed_items", len(items)), zap.Int("kept", kept),
+			zap.Int("would_add", len(articlesToDownload)), zap.Int("would_delete", len(toDelete)))
+		for _, a := range articlesToDownload {
+			log.Info("would add article", zap.String("sha", a.SHA), zap.String("url", a.URLs[0]), zap.String("title", a.Title))
+		}
+		for _, key := range toDelete {
+			log.Info("would delete article", zap.String("key", key))
+		}
+		return
+	}
+
+	progress := newDownloadProgress(*showProgress, len(articlesToDownload))
+	deleted := deleteArticles(ctx, newIndex, store, log)
+	downloaded, failures := downloadArticles(ctx, articlesToDownload, newIndex, store, fetcher, *workers, progress, log)
+	if deleted > 0 || downloaded > 0 || isLegacy {
+		sort.SliceStable(newIndex, func(i, j int) bool {
+			return newIndex[i].SHA < newIndex[j].SHA
+		})
+		data, err := encodeIndex(newIndex)
+		if err != nil {
+			log.Fatal("encoding index", zap.Error(err))
+		}
+		if err := store.PutIndex(ctx, data); err != nil {
+			log.Fatal("writing index", zap.Error(err))
+		}
+	}
+
+	report := RunReport{
+		Timestamp:  time.Now(),
+		FeedItems:  len(items),
+		Kept:       kept,
+		Added:      len(articlesToDownload),
+		Deleted:    deleted,
+		Failed:     len(failures),
+		Failures:   failures,
+		WallTimeMS: time.Since(start).Milliseconds(),
+	}
+	log.Info("run complete",
+		zap.Int("feed_items", report.FeedItems), zap.Int("kept", report.Kept), zap.Int("added", report.Added),
+		zap.Int("deleted", report.Deleted), zap.Int("failed", report.Failed), zap.Int64("duration_ms", report.WallTimeMS))
+	if err := writeReport(ctx, store, report); err != nil {
+		log.Error("writing run report", zap.Error(err))
+	}
 }
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDiffArticlesNewItem(t *testing.T) {
+	now := time.Now()
+	feedItems := []resolvedFeedItem{
+		{FeedItem: FeedItem{URL: "https://example.com/a", Name: "A", Lang: "zh"}, SHA: "sha-a"},
+	}
+
+	toDownload, newIndex := diffArticles(nil, feedItems, now)
+
+	if len(toDownload) != 1 || len(newIndex) != 1 {
+		t.Fatalf("got %d to download, %d in index, want 1 and 1", len(toDownload), len(newIndex))
+	}
+	if newIndex[0].SHA != "sha-a" || newIndex[0].Lang != "zh" {
+		t.Errorf("unexpected article: %+v", newIndex[0])
+	}
+	if !newIndex[0].FirstSeen.Equal(now) || !newIndex[0].LastSeen.Equal(now) {
+		t.Errorf("expected FirstSeen and LastSeen to be stamped with now")
+	}
+}
+
+func TestDiffArticlesKeptItemUpdatesLastSeenAndMergesURLs(t *testing.T) {
+	firstSeen := time.Now().Add(-24 * time.Hour)
+	now := time.Now()
+	current := []Article{
+		{SHA: "sha-a", URLs: []string{"https://example.com/a"}, Title: "A", FirstSeen: firstSeen, LastSeen: firstSeen},
+	}
+	feedItems := []resolvedFeedItem{
+		{FeedItem: FeedItem{URL: "https://example.com/a-alias"}, SHA: "sha-a"},
+	}
+
+	toDownload, newIndex := diffArticles(current, feedItems, now)
+
+	if len(toDownload) != 0 {
+		t.Fatalf("a previously-seen article should not be queued for download, got %d", len(toDownload))
+	}
+	if len(newIndex) != 1 {
+		t.Fatalf("got %d articles in index, want 1", len(newIndex))
+	}
+	a := newIndex[0]
+	if !a.FirstSeen.Equal(firstSeen) {
+		t.Errorf("FirstSeen should be preserved across runs, got %v want %v", a.FirstSeen, firstSeen)
+	}
+	if !a.LastSeen.Equal(now) {
+		t.Errorf("LastSeen should be refreshed to now, got %v want %v", a.LastSeen, now)
+	}
+	if len(a.URLs) != 2 || a.URLs[0] != "https://example.com/a" || a.URLs[1] != "https://example.com/a-alias" {
+		t.Errorf("expected the new alias URL to be appended, got %+v", a.URLs)
+	}
+}
+
+func TestDiffArticlesDuplicateURLNotAppendedTwice(t *testing.T) {
+	now := time.Now()
+	current := []Article{
+		{SHA: "sha-a", URLs: []string{"https://example.com/a"}, FirstSeen: now, LastSeen: now},
+	}
+	feedItems := []resolvedFeedItem{
+		{FeedItem: FeedItem{URL: "https://example.com/a"}, SHA: "sha-a"},
+	}
+
+	_, newIndex := diffArticles(current, feedItems, now)
+
+	if len(newIndex) != 1 || len(newIndex[0].URLs) != 1 {
+		t.Fatalf("expected the duplicate URL to be a no-op, got %+v", newIndex)
+	}
+}
+
+func TestDiffArticlesTwoFeedItemsSameRunMergeToOneArticle(t *testing.T) {
+	now := time.Now()
+	feedItems := []resolvedFeedItem{
+		{FeedItem: FeedItem{URL: "https://example.com/a"}, SHA: "sha-a"},
+		{FeedItem: FeedItem{URL: "https://example.com/a-alias"}, SHA: "sha-a"},
+	}
+
+	toDownload, newIndex := diffArticles(nil, feedItems, now)
+
+	if len(toDownload) != 1 {
+		t.Fatalf("two feed items resolving to the same SHA should queue one download, got %d", len(toDownload))
+	}
+	if len(newIndex) != 1 || len(newIndex[0].URLs) != 2 {
+		t.Fatalf("expected both URLs merged onto one index entry, got %+v", newIndex)
+	}
+}
+
+func TestDiffArticlesEmptyLangDoesNotOverwritePrior(t *testing.T) {
+	now := time.Now()
+	current := []Article{
+		{SHA: "sha-a", URLs: []string{"https://example.com/a"}, Lang: "zh", FirstSeen: now, LastSeen: now},
+	}
+	feedItems := []resolvedFeedItem{
+		{FeedItem: FeedItem{URL: "https://example.com/a"}, SHA: "sha-a"},
+	}
+
+	_, newIndex := diffArticles(current, feedItems, now)
+
+	if newIndex[0].Lang != "zh" {
+		t.Errorf("Lang = %q, want zh to be preserved when the feed item carries no Lang", newIndex[0].Lang)
+	}
+}
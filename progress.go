@@ -0,0 +1,50 @@
+package main
+
+import (
+	"sync/atomic"
+
+	"github.com/cheggaaa/pb/v3"
+)
+
+// downloadProgress renders a live progress bar over article downloads when
+// enabled, showing completed/total, failures, and current throughput. A nil
+// *downloadProgress is always safe to call into, so callers don't need to
+// branch on whether --progress was set.
+type downloadProgress struct {
+	bar    *pb.ProgressBar
+	failed uint64
+}
+
+const progressTemplate = `{{counters . }} {{bar . }} {{percent . }} failed={{string . "failed"}} {{speed . }} {{etime . }}`
+
+func newDownloadProgress(enabled bool, total int) *downloadProgress {
+	if !enabled {
+		return nil
+	}
+	bar := pb.ProgressBarTemplate(progressTemplate).Start(total)
+	bar.Set("failed", 0)
+	return &downloadProgress{bar: bar}
+}
+
+func (p *downloadProgress) Success() {
+	if p == nil {
+		return
+	}
+	p.bar.Increment()
+}
+
+func (p *downloadProgress) Failure() {
+	if p == nil {
+		return
+	}
+	failed := atomic.AddUint64(&p.failed, 1)
+	p.bar.Set("failed", failed)
+	p.bar.Increment()
+}
+
+func (p *downloadProgress) Finish() {
+	if p == nil {
+		return
+	}
+	p.bar.Finish()
+}
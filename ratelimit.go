@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/url"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// hostLimiter hands out a per-hostname rate.Limiter, creating one on first
+// use, so article downloads are paced politely per origin regardless of
+// how many partner feeds point at the same host.
+type hostLimiter struct {
+	rps   rate.Limit
+	burst int
+
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+func newHostLimiter(rps float64, burst int) *hostLimiter {
+	return &hostLimiter{
+		rps:      rate.Limit(rps),
+		burst:    burst,
+		limiters: make(map[string]*rate.Limiter),
+	}
+}
+
+func (h *hostLimiter) forURL(rawURL string) *rate.Limiter {
+	host := rawURL
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		host = u.Host
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	l, ok := h.limiters[host]
+	if !ok {
+		l = rate.NewLimiter(h.rps, h.burst)
+		h.limiters[host] = l
+	}
+	return l
+}
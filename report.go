@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// RunsPrefix namespaces run report objects so that ListArticles can tell
+// them apart from actual article content.
+const RunsPrefix = "runs/"
+
+// FailedArticle records why a single article could not be downloaded, for
+// inclusion in the run report.
+type FailedArticle struct {
+	SHA      string `json:"sha"`
+	URL      string `json:"url"`
+	Attempts int    `json:"attempts"`
+	Error    string `json:"error"`
+}
+
+// RunReport summarizes a single crawl run, so operators can alert on
+// failure rates and diff runs over time without parsing logs.
+type RunReport struct {
+	Timestamp  time.Time       `json:"timestamp"`
+	FeedItems  int             `json:"feed_items"`
+	Kept       int             `json:"kept"`
+	Added      int             `json:"added"`
+	Deleted    int             `json:"deleted"`
+	Failed     int             `json:"failed"`
+	Failures   []FailedArticle `json:"failures,omitempty"`
+	WallTimeMS int64           `json:"wall_time_ms"`
+}
+
+// writeReport marshals the report and stores it as a sibling object to the
+// index, keyed by the run's timestamp.
+func writeReport(ctx context.Context, store Store, report RunReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	key := fmt.Sprintf("%s%s.json", RunsPrefix, report.Timestamp.UTC().Format("20060102T150405Z"))
+	return store.PutArticle(ctx, key, data)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+)
+
+// retryConfig controls the retry helper's attempt count and backoff shape.
+type retryConfig struct {
+	MaxAttempts   int
+	PerAttempt    time.Duration
+	BackoffBase   time.Duration
+	BackoffFactor float64
+	BackoffCap    time.Duration
+}
+
+// defaultRetryConfig is used for both article fetches and store writes.
+var defaultRetryConfig = retryConfig{
+	MaxAttempts:   4,
+	PerAttempt:    30 * time.Second,
+	BackoffBase:   500 * time.Millisecond,
+	BackoffFactor: 2,
+	BackoffCap:    30 * time.Second,
+}
+
+// attemptResult records the outcome of a whole withRetry call (how many
+// attempts were made and the final error), surfaced so callers can report
+// per-article attempt counts in the run summary.
+type attemptResult struct {
+	Attempts int
+	Err      error
+}
+
+// withRetry calls fn until it succeeds, fn returns a permanent error, or
+// cfg.MaxAttempts is exhausted. Between attempts it sleeps for a jittered
+// exponential backoff. It returns the number of attempts made and the last
+// error, if any.
+func withRetry(ctx context.Context, cfg retryConfig, fn func(ctx context.Context) error) attemptResult {
+	var lastErr error
+	attempt := 1
+	for ; attempt <= cfg.MaxAttempts; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, cfg.PerAttempt)
+		err := fn(attemptCtx)
+		cancel()
+		if err == nil {
+			return attemptResult{Attempts: attempt}
+		}
+		lastErr = err
+		if !isRetryable(err) || attempt == cfg.MaxAttempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return attemptResult{Attempts: attempt, Err: ctx.Err()}
+		case <-time.After(backoff(cfg, attempt)):
+		}
+	}
+	return attemptResult{Attempts: attempt, Err: lastErr}
+}
+
+// backoff computes a jittered exponential delay for the given attempt
+// number (1-indexed), capped at cfg.BackoffCap.
+func backoff(cfg retryConfig, attempt int) time.Duration {
+	d := float64(cfg.BackoffBase)
+	for i := 1; i < attempt; i++ {
+		d *= cfg.BackoffFactor
+	}
+	capped := time.Duration(d)
+	if capped > cfg.BackoffCap {
+		capped = cfg.BackoffCap
+	}
+	// full jitter: sleep somewhere between 0 and the capped delay
+	return time.Duration(rand.Int63n(int64(capped) + 1))
+}
+
+// isRetryable distinguishes transient errors (timeouts, 5xx/429 responses,
+// network blips) from permanent ones (4xx, parse errors) that retrying
+// cannot fix.
+func isRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) {
+		status := respErr.ResponseError.HTTPStatusCode()
+		return status >= http.StatusInternalServerError || status == http.StatusTooManyRequests
+	}
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= http.StatusInternalServerError || statusErr.StatusCode == http.StatusTooManyRequests
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+// Store abstracts the persistence backend for the article index and
+// rendered article content, so the crawler can target S3, GCS, or the
+// local filesystem without touching the crawl logic. It deals in raw
+// bytes; encoding the index and deciding article keys is the caller's job.
+type Store interface {
+	// GetIndex returns the raw bytes of the current index, or a nil slice
+	// if none has been written yet.
+	GetIndex(ctx context.Context) ([]byte, error)
+	// PutIndex writes the given index bytes, overwriting any existing one.
+	PutIndex(ctx context.Context, data []byte) error
+	// PutArticle stores content under a key (an article's content, or any
+	// other sibling object such as a run report).
+	PutArticle(ctx context.Context, key string, content []byte) error
+	// GetArticle returns the content stored under a key.
+	GetArticle(ctx context.Context, key string) ([]byte, error)
+	// ListArticles returns the keys of all article objects currently stored
+	// (the index object itself is not included).
+	ListArticles(ctx context.Context) ([]string, error)
+	// DeleteArticles removes the objects with the given keys.
+	DeleteArticles(ctx context.Context, keys []string) error
+}
+
+// StoreDriver constructs a Store from a backend-specific URL, e.g.
+// "s3://bucket/prefix", "gs://bucket/prefix", or "file:///var/lib/ads".
+type StoreDriver func(rawURL string) (Store, error)
+
+var storeDrivers = map[string]StoreDriver{}
+
+// RegisterStoreDriver registers a StoreDriver under a URL scheme. It is
+// meant to be called from the init() of a driver implementation.
+func RegisterStoreDriver(scheme string, driver StoreDriver) {
+	storeDrivers[scheme] = driver
+}
+
+// NewStore looks up the driver for rawURL's scheme and constructs a Store
+// from it.
+func NewStore(rawURL string) (Store, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid store URL %q: %w", rawURL, err)
+	}
+	driver, ok := storeDrivers[u.Scheme]
+	if !ok {
+		return nil, fmt.Errorf("no store driver registered for scheme %q", u.Scheme)
+	}
+	return driver(rawURL)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	RegisterStoreDriver("file", newFSStore)
+}
+
+// fsStore stores the index and articles as files under a local directory.
+// It exists mainly so the crawler can be run and tested without AWS.
+type fsStore struct {
+	dir string
+}
+
+func newFSStore(rawURL string) (Store, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file store URL %q: %w", rawURL, err)
+	}
+	dir := u.Path
+	if dir == "" {
+		dir = u.Opaque
+	}
+	if dir == "" {
+		return nil, fmt.Errorf("file store URL %q is missing a path", rawURL)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &fsStore{dir: dir}, nil
+}
+
+func (s *fsStore) path(name string) string {
+	return filepath.Join(s.dir, name)
+}
+
+func (s *fsStore) get(key string) ([]byte, error) {
+	data, err := ioutil.ReadFile(s.path(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+func (s *fsStore) put(key string, data []byte) error {
+	p := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(p, data, 0o644)
+}
+
+func (s *fsStore) GetIndex(ctx context.Context) ([]byte, error) {
+	return s.get(LanternAdsIndex)
+}
+
+func (s *fsStore) PutIndex(ctx context.Context, data []byte) error {
+	return s.put(LanternAdsIndex, data)
+}
+
+func (s *fsStore) PutArticle(ctx context.Context, key string, content []byte) error {
+	return s.put(key, content)
+}
+
+func (s *fsStore) GetArticle(ctx context.Context, key string) ([]byte, error) {
+	return s.get(key)
+}
+
+func (s *fsStore) ListArticles(ctx context.Context) ([]string, error) {
+	var keys []string
+	err := filepath.Walk(s.dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(s.dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == LanternAdsIndex || strings.HasPrefix(rel, RunsPrefix) {
+			return nil
+		}
+		keys = append(keys, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot list store directory: %w", err)
+	}
+	return keys, nil
+}
+
+func (s *fsStore) DeleteArticles(ctx context.Context, keys []string) error {
+	for _, k := range keys {
+		if err := os.Remove(s.path(k)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("cannot delete old articles: %w", err)
+		}
+		_ = os.Remove(filepath.Dir(s.path(k))) // best-effort: drop the now-empty sha directory
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+func init() {
+	RegisterStoreDriver("gs", newGCSStore)
+}
+
+// gcsStore stores the index and articles as objects in a Google Cloud
+// Storage bucket, with an optional key prefix.
+type gcsStore struct {
+	client *storage.Client
+	bucket string
+	prefix string
+}
+
+func newGCSStore(rawURL string) (Store, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gs store URL %q: %w", rawURL, err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("gs store URL %q is missing a bucket name", rawURL)
+	}
+	client, err := storage.NewClient(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+	return &gcsStore{
+		client: client,
+		bucket: u.Host,
+		prefix: strings.Trim(u.Path, "/"),
+	}, nil
+}
+
+func (s *gcsStore) key(name string) string {
+	if s.prefix == "" {
+		return name
+	}
+	return s.prefix + "/" + name
+}
+
+func (s *gcsStore) object(key string) *storage.ObjectHandle {
+	return s.client.Bucket(s.bucket).Object(s.key(key))
+}
+
+func (s *gcsStore) get(ctx context.Context, key string) ([]byte, error) {
+	r, err := s.object(key).NewReader(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("problem accessing gcs bucket: %w", err)
+	}
+	defer func() { _ = r.Close() }()
+	return ioutil.ReadAll(r)
+}
+
+func (s *gcsStore) put(ctx context.Context, key string, data []byte) error {
+	w := s.object(key).NewWriter(ctx)
+	if _, err := w.Write(data); err != nil {
+		_ = w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func (s *gcsStore) GetIndex(ctx context.Context) ([]byte, error) {
+	return s.get(ctx, LanternAdsIndex)
+}
+
+func (s *gcsStore) PutIndex(ctx context.Context, data []byte) error {
+	return s.put(ctx, LanternAdsIndex, data)
+}
+
+func (s *gcsStore) PutArticle(ctx context.Context, key string, content []byte) error {
+	return s.put(ctx, key, content)
+}
+
+func (s *gcsStore) GetArticle(ctx context.Context, key string) ([]byte, error) {
+	return s.get(ctx, key)
+}
+
+func (s *gcsStore) ListArticles(ctx context.Context) ([]string, error) {
+	it := s.client.Bucket(s.bucket).Objects(ctx, &storage.Query{Prefix: s.prefix})
+	indexKey := s.key(LanternAdsIndex)
+	var keys []string
+	for {
+		attrs, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot list gcs bucket: %w", err)
+		}
+		if attrs.Name == indexKey {
+			continue
+		}
+		relKey := strings.TrimPrefix(strings.TrimPrefix(attrs.Name, s.prefix), "/")
+		if strings.HasPrefix(relKey, RunsPrefix) {
+			continue
+		}
+		keys = append(keys, relKey)
+	}
+	return keys, nil
+}
+
+func (s *gcsStore) DeleteArticles(ctx context.Context, keys []string) error {
+	for _, k := range keys {
+		if err := s.object(k).Delete(ctx); err != nil {
+			return fmt.Errorf("cannot delete old articles: %w", err)
+		}
+	}
+	return nil
+}
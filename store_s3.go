@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func init() {
+	RegisterStoreDriver("s3", newS3Store)
+}
+
+// s3Store stores the index and articles as objects in an S3 bucket, with
+// an optional key prefix.
+type s3Store struct {
+	client *s3.Client
+	bucket string
+	prefix string
+}
+
+func newS3Store(rawURL string) (Store, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid s3 store URL %q: %w", rawURL, err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("s3 store URL %q is missing a bucket name", rawURL)
+	}
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+	return &s3Store{
+		client: s3.NewFromConfig(cfg),
+		bucket: u.Host,
+		prefix: strings.Trim(u.Path, "/"),
+	}, nil
+}
+
+func (s *s3Store) key(name string) string {
+	if s.prefix == "" {
+		return name
+	}
+	return s.prefix + "/" + name
+}
+
+func (s *s3Store) get(ctx context.Context, key string) ([]byte, error) {
+	obj, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(key)),
+	})
+	if err != nil {
+		var responseError *awshttp.ResponseError
+		if errors.As(err, &responseError) && responseError.ResponseError.HTTPStatusCode() == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("problem accessing s3 bucket. check if env variables for AWS are set: %w", err)
+	}
+	defer func() { _ = obj.Body.Close() }()
+	return ioutil.ReadAll(obj.Body)
+}
+
+func (s *s3Store) put(ctx context.Context, key string, data []byte) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(key)),
+		Body:   bytes.NewReader(data),
+	})
+	return err
+}
+
+func (s *s3Store) GetIndex(ctx context.Context) ([]byte, error) {
+	return s.get(ctx, LanternAdsIndex)
+}
+
+func (s *s3Store) PutIndex(ctx context.Context, data []byte) error {
+	return s.put(ctx, LanternAdsIndex, data)
+}
+
+func (s *s3Store) PutArticle(ctx context.Context, key string, content []byte) error {
+	return s.put(ctx, key, content)
+}
+
+func (s *s3Store) GetArticle(ctx context.Context, key string) ([]byte, error) {
+	return s.get(ctx, key)
+}
+
+func (s *s3Store) ListArticles(ctx context.Context) ([]string, error) {
+	resp, err := s.client.ListObjects(ctx, &s3.ListObjectsInput{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(s.prefix),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot list s3 bucket: %w", err)
+	}
+	indexKey := s.key(LanternAdsIndex)
+	var keys []string
+	for _, item := range resp.Contents {
+		if *item.Key == indexKey {
+			continue
+		}
+		relKey := strings.TrimPrefix(strings.TrimPrefix(*item.Key, s.prefix), "/")
+		if strings.HasPrefix(relKey, RunsPrefix) {
+			continue
+		}
+		keys = append(keys, relKey)
+	}
+	return keys, nil
+}
+
+func (s *s3Store) DeleteArticles(ctx context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	objects := make([]types.ObjectIdentifier, len(keys))
+	for i, k := range keys {
+		objects[i] = types.ObjectIdentifier{Key: aws.String(s.key(k))}
+	}
+	_, err := s.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+		Bucket: aws.String(s.bucket),
+		Delete: &types.Delete{
+			Objects: objects,
+			Quiet:   true,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("cannot delete old articles: %w", err)
+	}
+	return nil
+}